@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/flynn/flynn/host/types"
+)
+
+// jobStarter starts and stops individual jobs on the host. It is
+// satisfied by the daemon's job state manager; tests can fake it.
+type jobStarter interface {
+	AddJob(job *host.Job) (*host.ActiveJob, error)
+	StopJob(id string) error
+}
+
+// serveJobBatch handles POST /host/jobs, the batch counterpart of the
+// existing single-job handler. It starts every job in the batch
+// atomically: the first job that fails to start causes every job
+// already started from the same batch to be stopped before the error
+// event is sent, so a caller never has to reconcile a half-started
+// batch by hand. Progress is streamed back as newline-delimited
+// host.Event JSON on the same connection the batch was posted on,
+// matching hostClient.RunJobs on the client side.
+func serveJobBatch(starter jobStarter, w http.ResponseWriter, req *http.Request) {
+	var jobs []*host.NewJob
+	if err := json.NewDecoder(req.Body).Decode(&jobs); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+
+	started := make([]*host.ActiveJob, 0, len(jobs))
+	for _, j := range jobs {
+		active, err := starter.AddJob(j.Job)
+		if err != nil {
+			for _, a := range started {
+				starter.StopJob(a.Job.ID)
+			}
+			enc.Encode(&host.Event{Event: host.EventTypeError, JobID: j.Job.ID})
+			return
+		}
+		started = append(started, active)
+		enc.Encode(&host.Event{Event: host.EventTypeStart, JobID: active.Job.ID, Job: active})
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
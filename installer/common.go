@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+)
+
+func loadCreds() aws.CredentialsProvider {
+	accessKeyID := os.Getenv("AWS_ACCESS_KEY_ID")
+	secretAccessKey := os.Getenv("AWS_ACCESS_SECRET")
+	securityToken := os.Getenv("AWS_SECURITY_TOKEN")
+
+	if (accessKeyID == "" || secretAccessKey == "") && securityToken == "" {
+		fatal(errors.New("AWS_ACCESS_KEY_ID and AWS_ACCESS_SECRET or AWS_SECURITY_TOKEN must be set"))
+	}
+	return aws.Creds(accessKeyID, secretAccessKey, securityToken)
+}
+
+func loadStackTemplate() string {
+	stackTemplateFile, err := os.Open("stack-template.json")
+	if err != nil {
+		fatal(err)
+	}
+	defer stackTemplateFile.Close()
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, stackTemplateFile); err != nil {
+		fatal(err)
+	}
+	return buf.String()
+}
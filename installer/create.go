@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/cloudformation"
+	"github.com/flynn/flynn/pkg/random"
+)
+
+func createCmd(args []string) {
+	set := flag.NewFlagSet("create", flag.ExitOnError)
+	name := set.String("name", "flynn", "name of the stack")
+	regionsFlag := set.String("regions", "us-east-1", "comma-separated list of regions to launch a stack in")
+	multiAZ := set.Bool("multi-az", false, "spread the ASG across at least three availability zones per region")
+	force := set.Bool("force", false, "create the stack even if the local state file already records one by this name, overwriting that record (an existing stack is not deleted)")
+	onFailure := set.String("on-failure", "teardown", "what to do if a region fails while creating a multi-region cluster: \"teardown\" deletes the sibling stacks too, \"rollback\" leaves each stack to roll back on its own")
+	tufRoot := set.String("tuf-root", "", "path to a file of TUF root keys, overriding the keys baked into the binary")
+	manifestURL := set.String("manifest-url", "", "URL of the TUF repository serving the EC2 image manifest")
+	set.Parse(args)
+
+	if *onFailure != "teardown" && *onFailure != "rollback" {
+		fatal(errors.New(fmt.Sprintf("invalid --on-failure %q, must be \"teardown\" or \"rollback\"", *onFailure)))
+	}
+
+	baseClusterDomain := os.Getenv("BASE_CLUSTER_DOMAIN")
+	if baseClusterDomain == "" {
+		fatal(errors.New("BASE_CLUSTER_DOMAIN is required"))
+	}
+	creds := loadCreds()
+
+	state, err := loadState()
+	if err != nil {
+		fatal(err)
+	}
+	if _, exists := state.Stacks[*name]; exists && !*force {
+		fatal(errors.New(fmt.Sprintf("stack %q already exists in %s, use --force to overwrite that record or run \"installer destroy\" first", *name, mustStatePath())))
+	}
+
+	regions := strings.Split(*regionsFlag, ",")
+	for i, region := range regions {
+		regions[i] = strings.TrimSpace(region)
+	}
+
+	stackTemplateString := loadStackTemplate()
+
+	latestVersion, err := fetchLatestVersion(*tufRoot, *manifestURL)
+	if err != nil {
+		fatal(err)
+	}
+
+	clusterDomain := random.Hex(16) + baseClusterDomain
+
+	// Validate every region up front and build its CreateStack params
+	// before starting any stack. A region failing imageIDForRegion or
+	// availabilityZones after siblings are already mid-create would
+	// otherwise force a choice between exiting with stacks left running
+	// unwatched or waiting on work that was never requested.
+	stacks := make([]*regionStack, len(regions))
+	states := make([]StackState, len(regions))
+	paramsByRegion := make([][]cloudformation.Parameter, len(regions))
+	for i, region := range regions {
+		imageID := imageIDForRegion(latestVersion, region)
+		if imageID == "" {
+			fatal(errors.New(fmt.Sprintf("No image found for region %s", region)))
+		}
+
+		cf := cloudformation.New(creds, region, &http.Client{})
+		params := []cloudformation.Parameter{
+			{
+				ParameterKey:   aws.String("ImageId"),
+				ParameterValue: aws.String(imageID),
+			},
+			{
+				ParameterKey:   aws.String("ClusterDomain"),
+				ParameterValue: aws.String(clusterDomain),
+			},
+		}
+		if *multiAZ {
+			azs, err := availabilityZones(creds, region, minAvailabilityZones)
+			if err != nil {
+				fatal(err)
+			}
+			params = append(params, cloudformation.Parameter{
+				ParameterKey:   aws.String("AvailabilityZones"),
+				ParameterValue: aws.String(strings.Join(azs, ",")),
+			})
+		}
+
+		stacks[i] = &regionStack{region: region, cf: cf}
+		states[i] = StackState{
+			Region:        region,
+			ClusterDomain: clusterDomain,
+			AMI:           imageID,
+			TUFVersion:    latestVersion.Version,
+		}
+		paramsByRegion[i] = params
+	}
+
+	var wg sync.WaitGroup
+	for i, stack := range stacks {
+		params := paramsByRegion[i]
+		wg.Add(1)
+		go func(stack *regionStack, st *StackState, params []cloudformation.Parameter) {
+			defer wg.Done()
+			res, err := stack.cf.CreateStack(&cloudformation.CreateStackInput{
+				OnFailure:        aws.String("ROLLBACK"),
+				StackName:        aws.String(*name),
+				Tags:             []cloudformation.Tag{},
+				TemplateBody:     aws.String(stackTemplateString),
+				TimeoutInMinutes: aws.Integer(10),
+				Parameters:       params,
+			})
+			if err != nil {
+				stack.err = err
+				return
+			}
+			stack.id = *res.StackID
+			st.StackID = stack.id
+			fmt.Printf("[%s] %s\n", stack.region, stack.id)
+		}(stack, &states[i], params)
+	}
+	wg.Wait()
+
+	var failed, created []*regionStack
+	var createdStates []StackState
+	for i, stack := range stacks {
+		if stack.err != nil {
+			fmt.Printf("[%s] Error: %v\n", stack.region, stack.err)
+			failed = append(failed, stack)
+			continue
+		}
+		created = append(created, stack)
+		createdStates = append(createdStates, states[i])
+	}
+	if len(failed) > 0 && len(created) == 0 {
+		fatal(errors.New("failed to create any stacks"))
+	}
+
+	completed := waitForStackCompletion(created)
+	if len(failed) > 0 || !completed {
+		// A region whose CreateStack call itself errored never reaches
+		// waitForStackCompletion, so it must be folded into the same
+		// failure branch as a stack that started but failed to
+		// complete - otherwise a single bad region leaves the rest of
+		// the cluster created, unreported, and untouched by
+		// --on-failure.
+		msg := "one or more stacks failed"
+		if len(failed) > 0 {
+			failedRegions := make([]string, len(failed))
+			for i, stack := range failed {
+				failedRegions[i] = stack.region
+			}
+			msg = fmt.Sprintf("stacks created in %d region(s) failed to start in region(s) %s", len(created), strings.Join(failedRegions, ", "))
+		}
+		if *onFailure == "teardown" {
+			tearDownStacks(created)
+			fatal(errors.New(msg + ", sibling stacks were torn down"))
+		}
+		fatal(errors.New(msg + ", each stack is rolling back on its own (--on-failure=rollback)"))
+	}
+
+	state.Stacks[*name] = createdStates
+	if err := state.save(); err != nil {
+		fatal(err)
+	}
+}
+
+func mustStatePath() string {
+	path, err := statePath()
+	if err != nil {
+		fatal(err)
+	}
+	return path
+}
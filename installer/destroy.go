@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/cloudformation"
+)
+
+func destroyCmd(args []string) {
+	set := flag.NewFlagSet("destroy", flag.ExitOnError)
+	name := set.String("name", "flynn", "name of the stack")
+	set.Parse(args)
+
+	creds := loadCreds()
+
+	state, err := loadState()
+	if err != nil {
+		fatal(err)
+	}
+	stackStates, ok := state.Stacks[*name]
+	if !ok {
+		fatal(errors.New(fmt.Sprintf("no stack named %q in the local state file", *name)))
+	}
+
+	// Issue DeleteStack for every region before fataling on any one
+	// region's error, the same way create/update collect per-region
+	// errors instead of aborting the rest of the cluster partway through.
+	var failed, deleting []*regionStack
+	for _, st := range stackStates {
+		cf := cloudformation.New(creds, st.Region, &http.Client{})
+		stack := &regionStack{region: st.Region, cf: cf, id: st.StackID}
+		if _, err := cf.DeleteStack(&cloudformation.DeleteStackInput{
+			StackName: aws.String(st.StackID),
+		}); err != nil {
+			fmt.Printf("[%s] Error: %v\n", stack.region, err)
+			failed = append(failed, stack)
+			continue
+		}
+		deleting = append(deleting, stack)
+	}
+
+	completed := waitForStackCompletion(deleting)
+	if len(failed) > 0 || !completed {
+		fatal(errors.New("one or more stacks failed to delete"))
+	}
+
+	delete(state.Stacks, *name)
+	if err := state.save(); err != nil {
+		fatal(err)
+	}
+}
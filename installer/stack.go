@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/cloudformation"
+	"github.com/awslabs/aws-sdk-go/gen/ec2"
+	r "github.com/flynn/flynn/util/release"
+)
+
+// minAvailabilityZones is the number of AZs the ASG is spread across when
+// --multi-az is given.
+const minAvailabilityZones = 3
+
+// regionStack tracks the CloudFormation stack managed in a single region.
+type regionStack struct {
+	region string
+	cf     *cloudformation.CloudFormation
+	id     string
+	err    error
+}
+
+func imageIDForRegion(v *r.EC2Version, region string) string {
+	for _, i := range v.Images {
+		if i.Region == region {
+			return i.ID
+		}
+	}
+	return ""
+}
+
+func availabilityZones(creds aws.CredentialsProvider, region string, min int) ([]string, error) {
+	e := ec2.New(creds, region, &http.Client{})
+	res, err := e.DescribeAvailabilityZones(&ec2.DescribeAvailabilityZonesRequest{
+		Filters: []ec2.Filter{
+			{Name: aws.String("state"), Values: []string{"available"}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.AvailabilityZones) < min {
+		return nil, errors.New(fmt.Sprintf("region %s only has %d available availability zones, need at least %d", region, len(res.AvailabilityZones), min))
+	}
+	azs := make([]string, 0, min)
+	for _, az := range res.AvailabilityZones {
+		azs = append(azs, *az.ZoneName)
+		if len(azs) == min {
+			break
+		}
+	}
+	return azs, nil
+}
+
+// failureSuffixes are the ResourceStatus suffixes that mean a stack (or a
+// change set applied to it) is never going to reach a successful COMPLETE
+// state on its own, so waitForStack should stop polling and report failure
+// instead of spinning forever.
+var failureSuffixes = []string{
+	"_FAILED",
+	"ROLLBACK_COMPLETE",
+}
+
+func isFailureState(state string) bool {
+	for _, suffix := range failureSuffixes {
+		if strings.HasSuffix(state, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// waitForStackCompletion multiplexes stack events from every stack in
+// stacks, prefixing each line with the stack's region so progress from
+// multiple regions can be followed on one terminal. It returns false if any
+// stack did not reach a successful *_COMPLETE state.
+func waitForStackCompletion(stacks []*regionStack) bool {
+	var wg sync.WaitGroup
+	results := make([]bool, len(stacks))
+	for i, stack := range stacks {
+		wg.Add(1)
+		go func(i int, stack *regionStack) {
+			defer wg.Done()
+			results[i] = waitForStack(stack)
+		}(i, stack)
+	}
+	wg.Wait()
+
+	ok := true
+	for _, r := range results {
+		if !r {
+			ok = false
+		}
+	}
+	return ok
+}
+
+func waitForStack(stack *regionStack) bool {
+	stackEvents := make([]cloudformation.StackEvent, 0)
+	var stackState string
+	var nextToken aws.StringValue
+
+	var fetchStackEvents func()
+	fetchStackEvents = func() {
+		res, err := stack.cf.DescribeStackEvents(&cloudformation.DescribeStackEventsInput{
+			NextToken: nextToken,
+			StackName: aws.String(stack.id),
+		})
+		if err != nil {
+			fmt.Printf("[%s] Error: %T{%v}\n", stack.region, err, err)
+			stack.err = err
+			return
+		}
+		// NOTE: some events are not returned in order (i.e. completion event returned before progress event)
+		for _, se := range res.StackEvents {
+			stackEventExists := false
+			for _, e := range stackEvents {
+				if *e.EventID == *se.EventID {
+					stackEventExists = true
+					break
+				}
+			}
+			if stackEventExists {
+				continue
+			}
+			stackEvents = append(stackEvents, se)
+			if se.ResourceType != nil && se.ResourceStatus != nil {
+				if *se.ResourceType == "AWS::CloudFormation::Stack" {
+					stackState = *se.ResourceStatus
+				}
+				fmt.Printf("[%s] %s %s\n", stack.region, *se.ResourceType, *se.ResourceStatus)
+				if se.ResourceStatusReason != nil {
+					fmt.Printf("[%s] \t%s\n", stack.region, *se.ResourceStatusReason)
+				}
+			}
+		}
+		if res.NextToken != nil {
+			nextToken = res.NextToken
+			fetchStackEvents()
+		}
+	}
+
+	for {
+		fetchStackEvents()
+		if stack.err != nil {
+			return false
+		}
+		if isFailureState(stackState) {
+			return false
+		}
+		if strings.HasSuffix(stackState, "_COMPLETE") {
+			return true
+		}
+		time.Sleep(1 * time.Second)
+	}
+}
+
+// tearDownStacks deletes every successfully created stack, used to avoid
+// leaving a half-built cluster behind when one region's stack fails.
+func tearDownStacks(stacks []*regionStack) {
+	var wg sync.WaitGroup
+	for _, stack := range stacks {
+		wg.Add(1)
+		go func(stack *regionStack) {
+			defer wg.Done()
+			fmt.Printf("[%s] Tearing down stack %s\n", stack.region, stack.id)
+			_, err := stack.cf.DeleteStack(&cloudformation.DeleteStackInput{
+				StackName: aws.String(stack.id),
+			})
+			if err != nil {
+				fmt.Printf("[%s] Error tearing down stack: %v\n", stack.region, err)
+				return
+			}
+			waitForStack(stack)
+		}(stack)
+	}
+	wg.Wait()
+}
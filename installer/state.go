@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// stateVersion increments when the on-disk format changes in an
+// incompatible way.
+const stateVersion = 1
+
+// StackState records everything needed to update or destroy a stack that
+// was previously created, without having to query CloudFormation first.
+type StackState struct {
+	StackID       string `json:"stack_id"`
+	Region        string `json:"region"`
+	ClusterDomain string `json:"cluster_domain"`
+	AMI           string `json:"ami"`
+	TUFVersion    string `json:"tuf_version"`
+}
+
+// State is the contents of the local installer state file, keyed by the
+// CloudFormation stack name (e.g. "flynn").
+type State struct {
+	Version int                     `json:"version"`
+	Stacks  map[string][]StackState `json:"stacks"`
+}
+
+func statePath() (string, error) {
+	home := os.Getenv("HOME")
+	if home == "" {
+		return "", errors.New("HOME must be set to locate the installer state file")
+	}
+	return filepath.Join(home, ".flynn", "installer.json"), nil
+}
+
+func loadState() (*State, error) {
+	path, err := statePath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &State{Version: stateVersion, Stacks: make(map[string][]StackState)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	state := &State{Stacks: make(map[string][]StackState)}
+	if err := json.NewDecoder(f).Decode(state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (s *State) save() error {
+	path, err := statePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/cloudformation"
+)
+
+func statusCmd(args []string) {
+	set := flag.NewFlagSet("status", flag.ExitOnError)
+	name := set.String("name", "flynn", "name of the stack")
+	set.Parse(args)
+
+	creds := loadCreds()
+
+	state, err := loadState()
+	if err != nil {
+		fatal(err)
+	}
+	stackStates, ok := state.Stacks[*name]
+	if !ok {
+		fatal(errors.New(fmt.Sprintf("no stack named %q in the local state file", *name)))
+	}
+
+	for _, st := range stackStates {
+		cf := cloudformation.New(creds, st.Region, &http.Client{})
+		res, err := cf.DescribeStacks(&cloudformation.DescribeStacksInput{
+			StackName: aws.String(st.StackID),
+		})
+		if err != nil {
+			fmt.Printf("[%s] Error: %v\n", st.Region, err)
+			continue
+		}
+		for _, s := range res.Stacks {
+			fmt.Printf("[%s] %s %s (ami=%s tuf=%s domain=%s)\n", st.Region, *s.StackName, *s.StackStatus, st.AMI, st.TUFVersion, st.ClusterDomain)
+		}
+	}
+}
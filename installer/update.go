@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/cloudformation"
+)
+
+func updateCmd(args []string) {
+	set := flag.NewFlagSet("update", flag.ExitOnError)
+	name := set.String("name", "flynn", "name of the stack")
+	tufRoot := set.String("tuf-root", "", "path to a file of TUF root keys, overriding the keys baked into the binary")
+	manifestURL := set.String("manifest-url", "", "URL of the TUF repository serving the EC2 image manifest")
+	set.Parse(args)
+
+	creds := loadCreds()
+
+	state, err := loadState()
+	if err != nil {
+		fatal(err)
+	}
+	stackStates, ok := state.Stacks[*name]
+	if !ok {
+		fatal(errors.New(fmt.Sprintf("no stack named %q in the local state file, run \"installer create\" first", *name)))
+	}
+
+	stackTemplateString := loadStackTemplate()
+
+	latestVersion, err := fetchLatestVersion(*tufRoot, *manifestURL)
+	if err != nil {
+		fatal(err)
+	}
+
+	// Look up every region's image before issuing any UpdateStack call. A
+	// later region failing imageIDForRegion must not leave earlier
+	// regions' change sets issued but unwaited on.
+	imageIDs := make([]string, len(stackStates))
+	for i, st := range stackStates {
+		imageID := imageIDForRegion(latestVersion, st.Region)
+		if imageID == "" {
+			fatal(errors.New(fmt.Sprintf("No image found for region %s", st.Region)))
+		}
+		imageIDs[i] = imageID
+	}
+
+	stacks := make([]*regionStack, len(stackStates))
+	for i := range stackStates {
+		st := &stackStates[i]
+		imageID := imageIDs[i]
+
+		cf := cloudformation.New(creds, st.Region, &http.Client{})
+		stack := &regionStack{region: st.Region, cf: cf, id: st.StackID}
+		stacks[i] = stack
+
+		if imageID == st.AMI {
+			fmt.Printf("[%s] Already up to date (%s)\n", st.Region, imageID)
+			continue
+		}
+
+		params := []cloudformation.Parameter{
+			{
+				ParameterKey:   aws.String("ImageId"),
+				ParameterValue: aws.String(imageID),
+			},
+			{
+				ParameterKey:     aws.String("ClusterDomain"),
+				UsePreviousValue: aws.Boolean(true),
+			},
+		}
+		_, err := cf.UpdateStack(&cloudformation.UpdateStackInput{
+			StackName:  aws.String(st.StackID),
+			Parameters: params,
+		})
+		if err != nil {
+			stack.err = err
+			continue
+		}
+		st.AMI = imageID
+		st.TUFVersion = latestVersion.Version
+	}
+
+	if !waitForStackCompletion(stacks) {
+		fatal(errors.New("one or more stacks failed to update"))
+	}
+
+	state.Stacks[*name] = stackStates
+	if err := state.save(); err != nil {
+		fatal(err)
+	}
+}
@@ -1,6 +1,7 @@
 package cluster
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -27,6 +28,12 @@ type Host interface {
 	// StopJob stops a running job.
 	StopJob(id string) error
 
+	// RunJobs starts a batch of jobs in a single round trip. Starting the
+	// batch is all-or-nothing: if any job fails to start, jobs already
+	// started from the same batch are stopped before the error is
+	// returned.
+	RunJobs(jobs []*host.NewJob) ([]*host.ActiveJob, error)
+
 	// StreamEvents about job state changes to ch. id may be "all" or a single
 	// job ID.
 	StreamEvents(id string, ch chan<- *host.Event) (stream.Stream, error)
@@ -96,6 +103,41 @@ func (c *hostClient) StopJob(id string) error {
 	return c.c.Delete(fmt.Sprintf("/host/jobs/%s", id))
 }
 
+func (c *hostClient) RunJobs(jobs []*host.NewJob) ([]*host.ActiveJob, error) {
+	body, err := json.Marshal(jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan *host.Event)
+	header := http.Header{"Content-Type": {"application/json"}}
+	stream, err := c.c.StreamWithHeader("POST", "/host/jobs", header, bytes.NewReader(body), events)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	active := make([]*host.ActiveJob, 0, len(jobs))
+	for event := range events {
+		switch event.Event {
+		case host.EventTypeError:
+			for _, a := range active {
+				c.StopJob(a.Job.ID)
+			}
+			return nil, fmt.Errorf("cluster: failed to start batch, job %s errored", event.JobID)
+		case host.EventTypeStart:
+			active = append(active, event.Job)
+		}
+	}
+	if len(active) != len(jobs) {
+		for _, a := range active {
+			c.StopJob(a.Job.ID)
+		}
+		return nil, fmt.Errorf("cluster: batch stream ended after %d of %d jobs started", len(active), len(jobs))
+	}
+	return active, nil
+}
+
 func (c *hostClient) StreamEvents(id string, ch chan<- *host.Event) (stream.Stream, error) {
 	r := fmt.Sprintf("/host/jobs/%s", id)
 	if id == "all" {
@@ -0,0 +1,197 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/flynn/flynn/host/types"
+)
+
+// Job metadata keys used to group jobs for affinity and anti-affinity
+// placement, matching the keys the controller already sets on every job.
+const (
+	appMetaKey     = "flynn-controller.app"
+	releaseMetaKey = "flynn-controller.release"
+)
+
+// PlacementPolicy decides how a batch of jobs is spread across a set of
+// hosts.
+type PlacementPolicy int
+
+const (
+	// PlacementSpread places jobs across as many hosts as possible.
+	PlacementSpread PlacementPolicy = iota
+
+	// PlacementBinpack fills one host before placing jobs on the next.
+	PlacementBinpack
+
+	// PlacementAffinity keeps jobs from the same app on the same host.
+	PlacementAffinity
+
+	// PlacementAntiAffinity spreads jobs from the same app across
+	// different hosts.
+	PlacementAntiAffinity
+
+	// PlacementAffinityRelease keeps jobs from the same release on the
+	// same host.
+	PlacementAffinityRelease
+
+	// PlacementAntiAffinityRelease spreads jobs from the same release
+	// across different hosts.
+	PlacementAntiAffinityRelease
+)
+
+// Placement is the host decided on for a single job in a batch, along with
+// the resulting ActiveJob once the batch has been started.
+type Placement struct {
+	Job    *host.NewJob
+	Host   Host
+	Active *host.ActiveJob
+}
+
+// Scheduler places a batch of jobs across a fixed set of hosts according to
+// a PlacementPolicy, then starts each host's share of the batch with a
+// single RunJobs call.
+type Scheduler struct {
+	Hosts  []Host
+	Policy PlacementPolicy
+}
+
+// NewScheduler creates a Scheduler that places jobs across hosts using
+// policy.
+func NewScheduler(hosts []Host, policy PlacementPolicy) *Scheduler {
+	return &Scheduler{Hosts: hosts, Policy: policy}
+}
+
+// Schedule decides a placement for every job in batch, starts each host's
+// share with a single RunJobs call, and returns the resulting placements
+// with their ActiveJob filled in. If any host fails to start its share,
+// jobs already started on other hosts are stopped and the error is
+// returned, so a formation update never leaves a partially-started batch
+// running.
+func (s *Scheduler) Schedule(batch []*host.NewJob) ([]Placement, error) {
+	if len(s.Hosts) == 0 {
+		return nil, errors.New("cluster: no hosts to schedule jobs on")
+	}
+
+	placements := s.place(batch)
+
+	byHost := make(map[Host][]*host.NewJob, len(s.Hosts))
+	for _, p := range placements {
+		byHost[p.Host] = append(byHost[p.Host], p.Job)
+	}
+
+	// Start each host's share in s.Hosts order, so the order of RunJobs
+	// calls (and what a failure rolls back) does not depend on map order.
+	started := make(map[Host][]*host.ActiveJob, len(byHost))
+	for _, h := range s.Hosts {
+		jobs, ok := byHost[h]
+		if !ok {
+			continue
+		}
+		delete(byHost, h)
+		active, err := h.RunJobs(jobs)
+		if err != nil {
+			s.rollback(started)
+			return nil, fmt.Errorf("cluster: scheduling batch failed on host %s: %s", h.ID(), err)
+		}
+		started[h] = active
+	}
+
+	for i, p := range placements {
+		for _, active := range started[p.Host] {
+			if active.Job.ID == p.Job.Job.ID {
+				placements[i].Active = active
+				break
+			}
+		}
+	}
+	return placements, nil
+}
+
+// rollback stops every job already started in a failed batch.
+func (s *Scheduler) rollback(started map[Host][]*host.ActiveJob) {
+	for h, jobs := range started {
+		for _, job := range jobs {
+			h.StopJob(job.Job.ID)
+		}
+	}
+}
+
+// place decides which host each job in batch should run on according to
+// the Scheduler's PlacementPolicy.
+func (s *Scheduler) place(batch []*host.NewJob) []Placement {
+	placements := make([]Placement, len(batch))
+
+	switch s.Policy {
+	case PlacementBinpack:
+		// Fill one host before placing jobs on the next. The Host
+		// interface carries no capacity signal, so an even share of
+		// the batch per host is the best available proxy: host 0
+		// fills first, then host 1, and so on, rather than every job
+		// piling onto a single host regardless of batch size.
+		perHost := (len(batch) + len(s.Hosts) - 1) / len(s.Hosts)
+		for i, job := range batch {
+			idx := i / perHost
+			if idx >= len(s.Hosts) {
+				idx = len(s.Hosts) - 1
+			}
+			placements[i] = Placement{Job: job, Host: s.Hosts[idx]}
+		}
+	case PlacementAffinity:
+		placements = s.placeAffinity(batch, appMetaKey)
+	case PlacementAntiAffinity:
+		placements = s.placeAntiAffinity(batch, appMetaKey)
+	case PlacementAffinityRelease:
+		placements = s.placeAffinity(batch, releaseMetaKey)
+	case PlacementAntiAffinityRelease:
+		placements = s.placeAntiAffinity(batch, releaseMetaKey)
+	default: // PlacementSpread
+		for i, job := range batch {
+			placements[i] = Placement{Job: job, Host: s.Hosts[i%len(s.Hosts)]}
+		}
+	}
+	return placements
+}
+
+// placeAffinity keeps jobs sharing the same metaKey value on the same
+// host.
+func (s *Scheduler) placeAffinity(batch []*host.NewJob, metaKey string) []Placement {
+	placements := make([]Placement, len(batch))
+	byKey := make(map[string]Host)
+	next := 0
+	for i, job := range batch {
+		key := jobMeta(job, metaKey)
+		h, ok := byKey[key]
+		if !ok || key == "" {
+			h = s.Hosts[next%len(s.Hosts)]
+			next++
+			if key != "" {
+				byKey[key] = h
+			}
+		}
+		placements[i] = Placement{Job: job, Host: h}
+	}
+	return placements
+}
+
+// placeAntiAffinity spreads jobs sharing the same metaKey value across
+// different hosts.
+func (s *Scheduler) placeAntiAffinity(batch []*host.NewJob, metaKey string) []Placement {
+	placements := make([]Placement, len(batch))
+	counts := make(map[string]int)
+	for i, job := range batch {
+		key := jobMeta(job, metaKey)
+		h := s.Hosts[counts[key]%len(s.Hosts)]
+		counts[key]++
+		placements[i] = Placement{Job: job, Host: h}
+	}
+	return placements
+}
+
+func jobMeta(job *host.NewJob, key string) string {
+	if job.Job == nil || job.Job.Metadata == nil {
+		return ""
+	}
+	return job.Job.Metadata[key]
+}
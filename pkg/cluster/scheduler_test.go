@@ -0,0 +1,196 @@
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/flynn/flynn/host/types"
+	"github.com/flynn/flynn/host/volume"
+	"github.com/flynn/flynn/pinkerton/layer"
+	"github.com/flynn/flynn/pkg/stream"
+)
+
+// fakeHost is a minimal Host used to exercise Scheduler placement and
+// rollback without any real host daemon.
+type fakeHost struct {
+	id      string
+	runErr  error
+	batches [][]string
+	stopped []string
+}
+
+func (h *fakeHost) ID() string                                   { return h.id }
+func (h *fakeHost) ListJobs() (map[string]host.ActiveJob, error) { return nil, nil }
+func (h *fakeHost) GetJob(id string) (*host.ActiveJob, error)    { return nil, nil }
+
+func (h *fakeHost) StopJob(id string) error {
+	h.stopped = append(h.stopped, id)
+	return nil
+}
+
+func (h *fakeHost) RunJobs(jobs []*host.NewJob) ([]*host.ActiveJob, error) {
+	if h.runErr != nil {
+		return nil, h.runErr
+	}
+	ids := make([]string, len(jobs))
+	active := make([]*host.ActiveJob, len(jobs))
+	for i, j := range jobs {
+		ids[i] = j.Job.ID
+		active[i] = &host.ActiveJob{Job: j.Job}
+	}
+	h.batches = append(h.batches, ids)
+	return active, nil
+}
+
+func (h *fakeHost) StreamEvents(id string, ch chan<- *host.Event) (stream.Stream, error) {
+	return nil, nil
+}
+func (h *fakeHost) Attach(req *host.AttachReq, wait bool) (AttachClient, error) { return nil, nil }
+func (h *fakeHost) CreateVolume(providerID string) (*volume.Info, error)        { return nil, nil }
+func (h *fakeHost) DestroyVolume(volumeID string) error                        { return nil }
+func (h *fakeHost) CreateSnapshot(volumeID string) (*volume.Info, error)       { return nil, nil }
+func (h *fakeHost) PullSnapshot(receiveVolID, sourceHostID, sourceSnapID string) (*volume.Info, error) {
+	return nil, nil
+}
+func (h *fakeHost) SendSnapshot(snapID string, assumeHaves []json.RawMessage) (io.ReadCloser, error) {
+	return nil, nil
+}
+func (h *fakeHost) PullImages(repository, driver, root string, tufDB io.Reader, ch chan<- *layer.PullInfo) (stream.Stream, error) {
+	return nil, nil
+}
+
+func newJob(id, app, release string) *host.NewJob {
+	return &host.NewJob{Job: &host.Job{
+		ID: id,
+		Metadata: map[string]string{
+			appMetaKey:     app,
+			releaseMetaKey: release,
+		},
+	}}
+}
+
+func hostsFor(placements []Placement, batch []*host.NewJob) map[string]string {
+	byHost := make(map[string]string, len(batch))
+	for i, p := range placements {
+		byHost[batch[i].Job.ID] = p.Host.ID()
+	}
+	return byHost
+}
+
+func TestPlaceSpread(t *testing.T) {
+	hosts := []Host{&fakeHost{id: "a"}, &fakeHost{id: "b"}}
+	s := NewScheduler(hosts, PlacementSpread)
+	batch := []*host.NewJob{newJob("1", "", ""), newJob("2", "", ""), newJob("3", "", ""), newJob("4", "", "")}
+
+	got := hostsFor(s.place(batch), batch)
+	want := map[string]string{"1": "a", "2": "b", "3": "a", "4": "b"}
+	for id, host := range want {
+		if got[id] != host {
+			t.Errorf("job %s: got host %s, want %s", id, got[id], host)
+		}
+	}
+}
+
+func TestPlaceBinpack(t *testing.T) {
+	hosts := []Host{&fakeHost{id: "a"}, &fakeHost{id: "b"}}
+	s := NewScheduler(hosts, PlacementBinpack)
+	batch := []*host.NewJob{newJob("1", "", ""), newJob("2", "", ""), newJob("3", "", "")}
+
+	got := hostsFor(s.place(batch), batch)
+	// perHost = ceil(3/2) = 2, so host a takes jobs 1-2 and host b takes
+	// the rest, instead of everything landing on host a.
+	want := map[string]string{"1": "a", "2": "a", "3": "b"}
+	for id, host := range want {
+		if got[id] != host {
+			t.Errorf("job %s: got host %s, want %s", id, got[id], host)
+		}
+	}
+}
+
+func TestPlaceAffinity(t *testing.T) {
+	hosts := []Host{&fakeHost{id: "a"}, &fakeHost{id: "b"}}
+	s := NewScheduler(hosts, PlacementAffinity)
+	batch := []*host.NewJob{
+		newJob("1", "app1", ""),
+		newJob("2", "app2", ""),
+		newJob("3", "app1", ""),
+	}
+
+	got := hostsFor(s.place(batch), batch)
+	if got["1"] != got["3"] {
+		t.Errorf("jobs from the same app should land on the same host: got %s and %s", got["1"], got["3"])
+	}
+	if got["2"] == got["1"] {
+		t.Errorf("expected app2's job to land on a different host than app1's, got %s for both", got["2"])
+	}
+}
+
+func TestPlaceAntiAffinity(t *testing.T) {
+	hosts := []Host{&fakeHost{id: "a"}, &fakeHost{id: "b"}}
+	s := NewScheduler(hosts, PlacementAntiAffinity)
+	batch := []*host.NewJob{
+		newJob("1", "app1", ""),
+		newJob("2", "app1", ""),
+		newJob("3", "app1", ""),
+	}
+
+	got := hostsFor(s.place(batch), batch)
+	if got["1"] == got["2"] {
+		t.Errorf("expected consecutive jobs from the same app to spread across hosts, both landed on %s", got["1"])
+	}
+	if got["1"] != got["3"] {
+		t.Errorf("expected the anti-affinity round robin to wrap back to the first host, got %s and %s", got["1"], got["3"])
+	}
+}
+
+func TestPlaceAffinityRelease(t *testing.T) {
+	hosts := []Host{&fakeHost{id: "a"}, &fakeHost{id: "b"}}
+	s := NewScheduler(hosts, PlacementAffinityRelease)
+	batch := []*host.NewJob{
+		newJob("1", "app1", "rel1"),
+		newJob("2", "app2", "rel1"),
+		newJob("3", "app3", "rel2"),
+	}
+
+	got := hostsFor(s.place(batch), batch)
+	if got["1"] != got["2"] {
+		t.Errorf("jobs from the same release should land on the same host regardless of app, got %s and %s", got["1"], got["2"])
+	}
+}
+
+func TestPlaceAntiAffinityRelease(t *testing.T) {
+	hosts := []Host{&fakeHost{id: "a"}, &fakeHost{id: "b"}}
+	s := NewScheduler(hosts, PlacementAntiAffinityRelease)
+	batch := []*host.NewJob{
+		newJob("1", "app1", "rel1"),
+		newJob("2", "app2", "rel1"),
+	}
+
+	got := hostsFor(s.place(batch), batch)
+	if got["1"] == got["2"] {
+		t.Errorf("jobs from the same release should spread across hosts, both landed on %s", got["1"])
+	}
+}
+
+func TestScheduleRollsBackOnPartialFailure(t *testing.T) {
+	good := &fakeHost{id: "good"}
+	bad := &fakeHost{id: "bad", runErr: errors.New("boom")}
+	s := NewScheduler([]Host{good, bad}, PlacementSpread)
+	batch := []*host.NewJob{newJob("1", "", ""), newJob("2", "", "")}
+
+	if _, err := s.Schedule(batch); err == nil {
+		t.Fatal("expected Schedule to return an error when one host fails to start its share")
+	}
+	if len(good.stopped) != 1 || good.stopped[0] != "1" {
+		t.Errorf("expected job started on the good host to be stopped on rollback, got %v", good.stopped)
+	}
+}
+
+func TestScheduleNoHosts(t *testing.T) {
+	s := NewScheduler(nil, PlacementSpread)
+	if _, err := s.Schedule([]*host.NewJob{newJob("1", "", "")}); err == nil {
+		t.Fatal("expected Schedule to error with no hosts to place jobs on")
+	}
+}